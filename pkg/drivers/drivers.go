@@ -17,10 +17,21 @@ limitations under the License.
 package drivers
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"syscall"
 
-	"github.com/code-ready/machine/libmachine/mcnflag"
 	"github.com/code-ready/machine/libmachine/drivers"
+	"github.com/code-ready/machine-driver-hyperkit/pkg/mcnflag"
+	"github.com/code-ready/machine-driver-hyperkit/pkg/mcnutils"
+	"github.com/code-ready/machine-driver-hyperkit/pkg/sshutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
 // GetDiskPath returns the path of the machine disk image
@@ -28,20 +39,71 @@ func GetDiskPath(d *drivers.BaseDriver) string {
 	return filepath.Join(d.ResolveStorePath("."), d.GetMachineName()+".rawdisk")
 }
 
-// CommonDriver is the common driver base class
+// CommonDriver is the common driver base class. Nothing in this tree embeds
+// it yet; it exists so a future driver that does share flags/config with
+// another driver has somewhere to put them.
 type CommonDriver struct{}
 
-//Not implemented yet
+// GetCreateFlags returns the flags common to every driver. CommonDriver has
+// no fields of its own, so there is nothing to register here; this is not
+// equivalent to a real driver's GetCreateFlags, it's just the empty case.
 func (d *CommonDriver) GetCreateFlags() []mcnflag.Flag {
-	return nil
+	return []mcnflag.Flag{}
 }
 
-//Not implemented yet
-func (d *CommonDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+// SetConfigFromFlags is a no-op: CommonDriver carries no configuration.
+func (d *CommonDriver) SetConfigFromFlags(flags DriverOptions) error {
 	return nil
 }
 
-/*
+// DriverOptions is the interface SetConfigFromFlags uses to read the values
+// registered via GetCreateFlags, regardless of how they were collected (CLI
+// flags, environment variables, or an RPC call to a plugin binary).
+type DriverOptions interface {
+	String(key string) string
+	StringSlice(key string) []string
+	Int(key string) int
+	Bool(key string) bool
+}
+
+// SSHDriver is implemented by drivers that can be reached over SSH, so
+// RunSSHCommandFromDriver can run commands on the guest without knowing
+// about any particular driver.
+type SSHDriver interface {
+	GetSSHHostname() (string, error)
+	GetSSHKeyPath() string
+	GetSSHPort() (int, error)
+	GetSSHUsername() string
+}
+
+// RunSSHCommandFromDriver runs command on the host addressed by d over SSH,
+// using the system ssh binary, and returns its combined output.
+func RunSSHCommandFromDriver(d SSHDriver, command string) (string, error) {
+	hostname, err := d.GetSSHHostname()
+	if err != nil {
+		return "", err
+	}
+	port, err := d.GetSSHPort()
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"-i", d.GetSSHKeyPath(),
+		"-p", strconv.Itoa(port),
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		fmt.Sprintf("%s@%s", d.GetSSHUsername(), hostname),
+		command,
+	}
+
+	out, err := exec.Command("ssh", args...).CombinedOutput()
+	if err != nil {
+		return string(out), err
+	}
+	return string(out), nil
+}
+
 func createRawDiskImage(sshKeyPath, diskPath string, diskSizeMb int) error {
 	tarBuf, err := mcnutils.MakeDiskImage(sshKeyPath)
 	if err != nil {
@@ -70,10 +132,16 @@ func createRawDiskImage(sshKeyPath, diskPath string, diskSizeMb int) error {
 	return nil
 }
 
+// sshKeyPath returns the path of the SSH private key whose public half
+// MakeDiskImage seeds into the guest's authorized_keys, stored alongside
+// the rest of the machine's files.
+func sshKeyPath(d *drivers.BaseDriver) string {
+	return d.ResolveStorePath("id_rsa")
+}
+
 func publicSSHKeyPath(d *drivers.BaseDriver) string {
-	return d.GetSSHKeyPath() + ".pub"
+	return sshKeyPath(d) + ".pub"
 }
-*/
 
 // Restart a host. This may just call Stop(); Start() if the provider does not
 // have any special restart behaviour.
@@ -86,37 +154,27 @@ func Restart(d drivers.Driver) error {
 	return nil
 }
 
-// MakeDiskImage makes a boot2docker VM disk image.
-func MakeDiskImage(d *drivers.BaseDriver) error {
-/*
-	//TODO(r2d4): rewrite this, not using b2dutils
-	b2dutils := mcnutils.NewB2dUtils(d.StorePath)
-	if err := b2dutils.CopyDiskToMachineDir(d.DiskPathURL, d.MachineName); err != nil {
-		return errors.Wrap(err, "Error copying disk image to machine dir")
-	}
-*/
-
-/*
-	log.Info("Creating ssh key...")
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+// MakeDiskImage makes a boot2docker VM disk image of diskSizeMb megabytes at
+// diskPath, seeded with an SSH key tarball so the guest can authenticate the
+// host.
+func MakeDiskImage(d *drivers.BaseDriver, diskPath string, diskSizeMb int) error {
+	log.Info("Creating SSH key...")
+	if err := sshutil.GenerateSSHKey(sshKeyPath(d)); err != nil {
 		return err
 	}
 
 	log.Info("Creating raw disk image...")
-	diskPath := GetDiskPath(d)
 	if _, err := os.Stat(diskPath); os.IsNotExist(err) {
-		if err := createRawDiskImage(publicSSHKeyPath(d), diskPath, diskSize); err != nil {
+		if err := createRawDiskImage(publicSSHKeyPath(d), diskPath, diskSizeMb); err != nil {
 			return err
 		}
 		if err := fixPermissions(d.ResolveStorePath(".")); err != nil {
 			return err
 		}
 	}
-*/
 	return nil
 }
 
-/*
 func fixPermissions(path string) error {
 	if err := os.Chown(path, syscall.Getuid(), syscall.Getegid()); err != nil {
 		return err
@@ -130,4 +188,3 @@ func fixPermissions(path string) error {
 	}
 	return nil
 }
-*/
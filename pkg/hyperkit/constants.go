@@ -4,7 +4,9 @@ const (
 	DriverName    = "hyperkit"
 	DriverVersion = "0.12.11"
 
-	DefaultMemory  = 8192
-	DefaultCPUs    = 4
-	DefaultSSHUser = "core"
+	DefaultMemory      = 8192
+	DefaultCPUs        = 4
+	DefaultDiskSize    = 20000
+	DefaultSSHUser     = "core"
+	DefaultImageFormat = "qcow2"
 )
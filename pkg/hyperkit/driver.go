@@ -1,3 +1,4 @@
+//go:build darwin
 // +build darwin
 
 /*
@@ -24,6 +25,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"syscall"
@@ -32,6 +34,9 @@ import (
 	hyperkitdriver "github.com/code-ready/machine/drivers/hyperkit"
 	"github.com/code-ready/machine/libmachine/drivers"
 	"github.com/code-ready/machine/libmachine/state"
+	hkdrivers "github.com/code-ready/machine-driver-hyperkit/pkg/drivers"
+	"github.com/code-ready/machine-driver-hyperkit/pkg/mcnflag"
+	"github.com/johanneswuerbach/nfsexports"
 	"github.com/mitchellh/go-ps"
 	hyperkit "github.com/moby/hyperkit/go"
 	"github.com/pkg/errors"
@@ -45,19 +50,166 @@ const (
 		"sudo chown root:wheel %s && sudo chmod u+s %s"
 )
 
-type Driver hyperkitdriver.Driver
+// Driver wraps the upstream hyperkit driver to add configuration
+// (disk size, boot2docker image, NFS shares) that code-ready/machine's
+// Driver does not itself carry.
+type Driver struct {
+	*hyperkitdriver.Driver
+	DiskSize       int
+	Boot2DockerURL string
+	NFSShares      []string
+	NFSSharesRoot  string
+}
 
 // NewDriver creates a new driver for a host
 func NewDriver() *Driver {
 	return &Driver{
-		VMDriver: &drivers.VMDriver{
-			BaseDriver: &drivers.BaseDriver{},
-			CPU:        DefaultCPUs,
-			Memory:     DefaultMemory,
+		Driver: &hyperkitdriver.Driver{
+			VMDriver: &drivers.VMDriver{
+				BaseDriver: &drivers.BaseDriver{},
+				CPU:        DefaultCPUs,
+				Memory:     DefaultMemory,
+			},
 		},
 	}
 }
 
+// GetCreateFlags registers the hyperkit-specific command line flags
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.IntFlag{
+			Name:   "hyperkit-cpu-count",
+			Usage:  "number of CPUs for the machine (-1 to use the number of CPUs available)",
+			EnvVar: "HYPERKIT_CPU_COUNT",
+			Value:  DefaultCPUs,
+		},
+		mcnflag.IntFlag{
+			Name:   "hyperkit-memory",
+			Usage:  "size of memory for the machine (in MB)",
+			EnvVar: "HYPERKIT_MEMORY_SIZE",
+			Value:  DefaultMemory,
+		},
+		mcnflag.IntFlag{
+			Name:   "hyperkit-disk-size",
+			Usage:  "size of disk for the machine (in MB)",
+			EnvVar: "HYPERKIT_DISK_SIZE",
+			Value:  DefaultDiskSize,
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-boot2docker-url",
+			Usage:  "URL of the boot2docker-style image to boot from",
+			EnvVar: "HYPERKIT_BOOT2DOCKER_URL",
+		},
+		mcnflag.BoolFlag{
+			Name:   "hyperkit-vmnet",
+			Usage:  "enable vmnet networking and assign the VM a routable IP",
+			EnvVar: "HYPERKIT_VMNET",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-uuid",
+			Usage:  "UUID used to identify the VM and derive its vmnet MAC address",
+			EnvVar: "HYPERKIT_UUID",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-vpnkit-sock",
+			Usage:  "path to the VPNKit socket used for networking",
+			EnvVar: "HYPERKIT_VPNKIT_SOCK",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-vsock-ports",
+			Usage:  "comma-separated list of guest VSock ports to expose",
+			EnvVar: "HYPERKIT_VSOCK_PORTS",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-vmlinuz",
+			Usage:  "path to the kernel to boot the VM with",
+			EnvVar: "HYPERKIT_VMLINUZ",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-initrd",
+			Usage:  "path to the initrd to boot the VM with",
+			EnvVar: "HYPERKIT_INITRD",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-cmdline",
+			Usage:  "kernel command line to boot the VM with",
+			EnvVar: "HYPERKIT_CMDLINE",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-image-format",
+			Usage:  "format of the VM disk image, qcow2 or raw",
+			EnvVar: "HYPERKIT_IMAGE_FORMAT",
+			Value:  DefaultImageFormat,
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-image-source-path",
+			Usage:  "path of an existing disk image to copy into the machine directory",
+			EnvVar: "HYPERKIT_IMAGE_SOURCE_PATH",
+		},
+		mcnflag.StringSliceFlag{
+			Name:   "hyperkit-nfs-share",
+			Usage:  "path of a host directory to NFS-export into the VM, may be used multiple times",
+			EnvVar: "HYPERKIT_NFS_SHARES",
+		},
+		mcnflag.StringFlag{
+			Name:   "hyperkit-nfs-shares-root",
+			Usage:  "host directory exported to the VM over NFS; every NFS share must lie under it and is mounted in the VM at its own path",
+			EnvVar: "HYPERKIT_NFS_SHARES_ROOT",
+			Value:  "/nfsshares",
+		},
+	}
+}
+
+// SetConfigFromFlags configures the driver from the flags registered in GetCreateFlags
+func (d *Driver) SetConfigFromFlags(flags hkdrivers.DriverOptions) error {
+	d.CPU = flags.Int("hyperkit-cpu-count")
+	d.Memory = flags.Int("hyperkit-memory")
+	d.DiskSize = flags.Int("hyperkit-disk-size")
+	d.Boot2DockerURL = flags.String("hyperkit-boot2docker-url")
+	d.VMNet = flags.Bool("hyperkit-vmnet")
+	d.UUID = flags.String("hyperkit-uuid")
+	d.VpnKitSock = flags.String("hyperkit-vpnkit-sock")
+	d.VSockPorts = nil
+	if ports := flags.String("hyperkit-vsock-ports"); ports != "" {
+		d.VSockPorts = strings.Split(ports, ",")
+	}
+	d.VmlinuzPath = flags.String("hyperkit-vmlinuz")
+	d.InitrdPath = flags.String("hyperkit-initrd")
+	d.Cmdline = flags.String("hyperkit-cmdline")
+	d.ImageFormat = flags.String("hyperkit-image-format")
+	d.ImageSourcePath = flags.String("hyperkit-image-source-path")
+	d.NFSShares = flags.StringSlice("hyperkit-nfs-share")
+	d.NFSSharesRoot = flags.String("hyperkit-nfs-shares-root")
+
+	if _, err := d.extractVSockPorts(); err != nil {
+		return err
+	}
+
+	switch d.ImageFormat {
+	case "qcow2", "raw":
+	default:
+		return fmt.Errorf("invalid hyperkit-image-format %q: must be qcow2 or raw", d.ImageFormat)
+	}
+
+	if d.VmlinuzPath != "" {
+		if _, err := os.Stat(d.VmlinuzPath); err != nil {
+			return errors.Wrap(err, "hyperkit-vmlinuz")
+		}
+	}
+	if d.InitrdPath != "" {
+		if _, err := os.Stat(d.InitrdPath); err != nil {
+			return errors.Wrap(err, "hyperkit-initrd")
+		}
+	}
+	if d.ImageSourcePath != "" {
+		if _, err := os.Stat(d.ImageSourcePath); err != nil {
+			return errors.Wrap(err, "hyperkit-image-source-path")
+		}
+	}
+
+	return nil
+}
+
 // PreCreateCheck is called to enforce pre-creation steps
 func (d *Driver) PreCreateCheck() error {
 	return d.verifyRootPermissions()
@@ -90,7 +242,11 @@ func (d *Driver) Create() error {
 		return err
 	}
 
-	if err := copyFile(d.ImageSourcePath, d.getDiskPath()); err != nil {
+	if d.ImageSourcePath == "" {
+		if err := hkdrivers.MakeDiskImage(d.BaseDriver, d.getDiskPath(), d.DiskSize); err != nil {
+			return errors.Wrap(err, "making disk image")
+		}
+	} else if err := copyFile(d.ImageSourcePath, d.getDiskPath()); err != nil {
 		return err
 	}
 
@@ -107,20 +263,57 @@ func (d *Driver) GetSSHHostname() (string, error) {
 	return d.IPAddress, nil
 }
 
+// GetSSHKeyPath returns the path to the SSH private key whose public half
+// hkdrivers.MakeDiskImage seeds into the boot2docker-style image, used to
+// authenticate with the machine.
+func (d *Driver) GetSSHKeyPath() string {
+	return d.ResolveStorePath("id_rsa")
+}
+
+// GetSSHPort returns the port to use for SSH. The boot2docker-style images
+// this driver boots always run sshd on the standard port.
+func (d *Driver) GetSSHPort() (int, error) {
+	return 22, nil
+}
+
+// GetSSHUsername returns the username to use for SSH.
+func (d *Driver) GetSSHUsername() string {
+	return DefaultSSHUser
+}
+
 // GetState returns the state that the host is in (running, stopped, etc)
 func (d *Driver) GetState() (state.State, error) {
 	if err := d.verifyRootPermissions(); err != nil {
 		return state.Error, err
 	}
 
+	if _, err := os.Stat(d.HyperKitPath); err != nil {
+		if os.IsNotExist(err) {
+			return state.Error, errors.Wrapf(ErrBinaryMissing, "%s", d.HyperKitPath)
+		}
+		return state.Error, err
+	}
+	if !isSetuidRoot(d.HyperKitPath) {
+		return state.Error, errors.Wrapf(ErrPermission, "%s", d.HyperKitPath)
+	}
+
 	p, err := d.findHyperkitProcess()
 	if err != nil {
 		return state.Error, err
 	}
-	if p == nil {
-		return state.Stopped, nil
+	if p != nil {
+		return state.Running, nil
+	}
+
+	record, err := d.readLastExit()
+	if err != nil {
+		return state.Error, err
 	}
-	return state.Running, nil
+	if record != nil {
+		return state.Error, errors.Wrapf(ErrCrashed, "hyperkit (pid %d) exited at %s", record.Pid, record.Time)
+	}
+
+	return state.Stopped, nil
 }
 
 // Kill stops a host forcefully
@@ -128,6 +321,9 @@ func (d *Driver) Kill() error {
 	if err := d.verifyRootPermissions(); err != nil {
 		return err
 	}
+	if err := d.markExpectedStop(); err != nil {
+		log.Debugf("failed to mark hyperkit as intentionally stopping: %v", err)
+	}
 	return d.sendSignal(syscall.SIGKILL)
 }
 
@@ -146,6 +342,11 @@ func (d *Driver) Remove() error {
 			return err
 		}
 	}
+
+	if err := d.teardownNFSShare(); err != nil {
+		return errors.Wrap(err, "tearing down NFS share")
+	}
+
 	return nil
 }
 
@@ -204,24 +405,33 @@ func (d *Driver) Start() error {
 		log.Debugf("Generated MAC %s", mac)
 	}
 
-	if d.ImageFormat != "qcow2" {
-		return fmt.Errorf("Unsupported VM image format: %s", d.ImageFormat)
-	}
-	h.Disks = []hyperkit.DiskConfig{
-		{
-			Path:   fmt.Sprintf("file://%s", d.getDiskPath()),
-			Driver: "virtio-blk",
-			Format: "qcow",
-		},
+	disk, err := hyperkit.NewDisk(fmt.Sprintf("file://%s", d.getDiskPath()), d.DiskSize)
+	if err != nil {
+		return errors.Wrap(err, "configuring disk")
 	}
+	h.Disks = []hyperkit.Disk{disk}
+
 	log.Debugf("Starting with cmdline: %s", d.Cmdline)
-	if err := h.Start(d.Cmdline); err != nil {
+	if _, err := h.Start(d.Cmdline); err != nil {
 		log.Debugf("Error trying to execute %s", h.CmdLine)
 		return errors.Wrapf(err, "starting with cmd line: %s", d.Cmdline)
 	}
 
 	log.Debugf("Trying to execute %s", h.CmdLine)
 
+	// This Start superseded whatever happened on the previous run: drop any
+	// stale crash record and expected-stop marker, and start watching the
+	// new child for an early exit.
+	if err := d.clearLastExit(); err != nil {
+		log.Debugf("error clearing stale crash record: %v", err)
+	}
+	d.consumeExpectedStop()
+	if pid, err := readPidFromFile(d.ResolveStorePath(pidFileName)); err != nil {
+		log.Debugf("could not read hyperkit pidfile to watch for crashes: %v", err)
+	} else {
+		go d.watchHyperkitProcess(pid)
+	}
+
 	waitUntilRunning := func() error {
 		st, err := d.GetState()
 		if err != nil {
@@ -253,6 +463,12 @@ func (d *Driver) Start() error {
 	}
 	log.Debugf("IP: %s", d.IPAddress)
 
+	if len(d.NFSShares) > 0 {
+		if err := d.setupNFSShare(); err != nil {
+			return errors.Wrap(err, "setting up NFS share")
+		}
+	}
+
 	return nil
 }
 
@@ -265,6 +481,124 @@ func (d *Driver) DriverVersion() string {
 	return DriverVersion
 }
 
+const (
+	lastExitFileName       = "last-exit.json"
+	expectedStopMarkerName = "stopping"
+)
+
+// ErrCrashed is wrapped by GetState when hyperkit started but exited on its
+// own, rather than being stopped or killed by us.
+var ErrCrashed = errors.New("hyperkit crashed")
+
+// ErrBinaryMissing is wrapped by GetState when the hyperkit binary at
+// HyperKitPath cannot be found.
+var ErrBinaryMissing = errors.New("hyperkit binary not found")
+
+// ErrPermission is wrapped by GetState when the hyperkit binary at
+// HyperKitPath is not setuid root, so it would not be able to run.
+var ErrPermission = errors.New("hyperkit binary is not setuid root")
+
+// lastExitRecord is persisted to lastExitFileName whenever the watcher
+// spawned by Start notices hyperkit exiting on its own.
+type lastExitRecord struct {
+	Pid  int       `json:"pid"`
+	Time time.Time `json:"time"`
+}
+
+func isSetuidRoot(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.Mode()&os.ModeSetuid == 0 {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Uid == 0
+}
+
+func (d *Driver) lastExitPath() string {
+	return d.ResolveStorePath(lastExitFileName)
+}
+
+func (d *Driver) writeLastExit(record lastExitRecord) error {
+	bs, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.lastExitPath(), bs, 0644)
+}
+
+func (d *Driver) readLastExit() (*lastExitRecord, error) {
+	bs, err := ioutil.ReadFile(d.lastExitPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "reading last-exit record")
+	}
+	var record lastExitRecord
+	if err := json.Unmarshal(bs, &record); err != nil {
+		return nil, errors.Wrap(err, "parsing last-exit record")
+	}
+	return &record, nil
+}
+
+func (d *Driver) clearLastExit() error {
+	if err := os.Remove(d.lastExitPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// markExpectedStop records that Stop/Kill is intentionally bringing hyperkit
+// down, so watchHyperkitProcess knows not to treat the exit as a crash.
+func (d *Driver) markExpectedStop() error {
+	return ioutil.WriteFile(d.ResolveStorePath(expectedStopMarkerName), []byte{}, 0644)
+}
+
+// consumeExpectedStop reports whether hyperkit was asked to stop, clearing
+// the marker so it only applies to the exit it was left for.
+func (d *Driver) consumeExpectedStop() bool {
+	marker := d.ResolveStorePath(expectedStopMarkerName)
+	if _, err := os.Stat(marker); err != nil {
+		return false
+	}
+	if err := os.Remove(marker); err != nil {
+		log.Debugf("error removing expected-stop marker: %v", err)
+	}
+	return true
+}
+
+// watchHyperkitProcess polls pid, the process Start just launched, until it
+// exits. hyperkit isn't always our direct child (it can re-exec itself to
+// gain its setuid privileges), so we can't just call Wait on it; polling the
+// pidfile's pid is the only thing that works regardless. If the process goes
+// away without Stop/Kill having asked it to, a crash record is written so the
+// next GetState call can report ErrCrashed instead of a clean Stopped.
+func (d *Driver) watchHyperkitProcess(pid int) {
+	for {
+		time.Sleep(2 * time.Second)
+		p, err := ps.FindProcess(pid)
+		if err != nil {
+			log.Debugf("error polling hyperkit pid %d: %v", pid, err)
+			return
+		}
+		if p == nil {
+			break
+		}
+	}
+
+	if d.consumeExpectedStop() {
+		return
+	}
+
+	record := lastExitRecord{Pid: pid, Time: time.Now()}
+	if err := d.writeLastExit(record); err != nil {
+		log.Debugf("error recording hyperkit crash: %v", err)
+	}
+}
+
 // recoverFromUncleanShutdown searches for an existing hyperkit.pid file in
 // the machine directory. If it can't find it, a clean shutdown is assumed.
 // If it finds the pid file, it checks for a running hyperkit process with that pid
@@ -278,6 +612,15 @@ func (d *Driver) recoverFromUncleanShutdown() error {
 		/* hyperkit is running, pid file can't be stale */
 		return nil
 	}
+
+	if record, err := d.readLastExit(); err != nil {
+		log.Debugf("error reading last-exit record: %v", err)
+	} else if record != nil {
+		// Preserve the crash record: GetState still needs it to report
+		// ErrCrashed instead of silently claiming the VM is stopped.
+		log.Debugf("hyperkit (pid %d) crashed at %s, preserving crash record", record.Pid, record.Time)
+	}
+
 	/* There might be a stale pid file, try to remove it */
 	pidFile := d.ResolveStorePath(pidFileName)
 	if err := os.Remove(pidFile); err != nil {
@@ -298,25 +641,36 @@ func (d *Driver) Stop() error {
 
 	s, err := d.GetState()
 	if err != nil {
-		return err
+		log.Debugf("Error checking machine status: %v, assuming there is nothing to stop", err)
 	}
 
-	if s != state.Stopped {
-		err := d.sendSignal(syscall.SIGTERM)
-		if err != nil {
+	if s == state.Running {
+		if err := d.markExpectedStop(); err != nil {
+			log.Debugf("failed to mark hyperkit as intentionally stopping: %v", err)
+		}
+		if err := d.sendSignal(syscall.SIGTERM); err != nil {
 			return errors.Wrap(err, "hyperkit sigterm failed")
 		}
 		// wait 120s for graceful shutdown
+		stopped := false
 		for i := 0; i < 60; i++ {
 			time.Sleep(2 * time.Second)
 			s, _ := d.GetState()
 			log.Debugf("VM state: %s", s)
 			if s == state.Stopped {
-				return nil
+				stopped = true
+				break
 			}
 		}
-		return errors.New("VM Failed to gracefully shutdown, try the kill command")
+		if !stopped {
+			return errors.New("VM Failed to gracefully shutdown, try the kill command")
+		}
+	}
+
+	if err := d.teardownNFSShare(); err != nil {
+		return errors.Wrap(err, "tearing down NFS share")
 	}
+
 	return nil
 }
 
@@ -329,6 +683,30 @@ func (port InvalidPortNumberError) Error() string {
 	return fmt.Sprintf("vsock port '%s' is not an integer", string(port))
 }
 
+// UnsupportedLiveChangeError implements the Error interface.
+// It is used by UpdateConfigRaw when a field can only change while the
+// machine is stopped.
+type UnsupportedLiveChangeError struct {
+	Field string
+}
+
+// Error returns an Error for UnsupportedLiveChangeError
+func (e *UnsupportedLiveChangeError) Error() string {
+	return fmt.Sprintf("%s cannot be changed while the machine is running, stop it first", e.Field)
+}
+
+// DiskShrinkError implements the Error interface.
+// It is used by UpdateConfigRaw when a config change would shrink the disk,
+// which isn't supported.
+type DiskShrinkError struct {
+	Old, New int
+}
+
+// Error returns an Error for DiskShrinkError
+func (e *DiskShrinkError) Error() string {
+	return fmt.Sprintf("disk size cannot be reduced (%d MB -> %d MB)", e.Old, e.New)
+}
+
 func (d *Driver) extractVSockPorts() ([]int, error) {
 	vsockPorts := make([]int, 0, len(d.VSockPorts))
 
@@ -410,20 +788,166 @@ func (d *Driver) findHyperkitProcess() (ps.Process, error) {
 	return p, nil
 }
 
-func (d *Driver) UpdateConfigRaw(rawConfig []byte) error {
-	var newDriver Driver
-	err := json.Unmarshal(rawConfig, &newDriver)
+// nfsExportIdentifier returns the comment nfsexports uses to tag the export
+// block it owns, keyed by machine name so it never collides with another
+// machine's share and can be found again on teardown.
+func (d *Driver) nfsExportIdentifier() string {
+	return fmt.Sprintf("machine-driver-hyperkit-%s", d.MachineName)
+}
+
+// hasNFSExport reports whether the system exports file already contains a
+// block tagged with this machine's identifier, e.g. left over from a
+// previous create/delete cycle.
+func (d *Driver) hasNFSExport() (bool, error) {
+	exists, err := nfsexports.Exists("", d.nfsExportIdentifier())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return exists, nil
+}
+
+// setupNFSShare registers a host NFS export rooted at NFSSharesRoot and
+// mounts each entry of NFSShares inside the guest.
+func (d *Driver) setupNFSShare() error {
+	hostIP, err := GetNetAddr()
+	if err != nil {
+		return errors.Wrap(err, "getting host IP")
+	}
+
+	mapping := fmt.Sprintf("%s -alldirs -mapall=%d:%d %s", d.NFSSharesRoot, syscall.Getuid(), syscall.Getgid(), d.IPAddress)
+
+	// A block from a previous create/delete cycle may still be there:
+	// replace it instead of leaving a stale duplicate. The old block is
+	// removed and the new one added as two separate writes of the exports
+	// file; nfsd only sees the result on the reload below.
+	stale, err := d.hasNFSExport()
 	if err != nil {
+		return errors.Wrap(err, "checking for existing NFS share")
+	}
+	if stale {
+		if _, err := nfsexports.Remove("", d.nfsExportIdentifier()); err != nil {
+			return errors.Wrap(err, "removing stale NFS share")
+		}
+	}
+	if _, err := nfsexports.Add("", d.nfsExportIdentifier(), mapping); err != nil {
+		return errors.Wrap(err, "adding NFS share")
+	}
+
+	if err := nfsexports.ReloadDaemon(); err != nil {
+		return errors.Wrap(err, "reloading nfsd")
+	}
+
+	for _, share := range d.NFSShares {
+		if err := d.mountNFSShare(hostIP.String(), share); err != nil {
+			return errors.Wrap(err, "mounting NFS share")
+		}
+	}
+
+	return nil
+}
+
+// mountNFSShare mounts a single NFS export inside the guest over SSH.
+func (d *Driver) mountNFSShare(hostIP, dir string) error {
+	log.Debugf("Mounting NFS share %s", dir)
+
+	if _, err := hkdrivers.RunSSHCommandFromDriver(d, fmt.Sprintf("sudo umount -f %s", dir)); err != nil {
+		log.Debugf("unmounting %s before remount failed, assuming it wasn't mounted: %v", dir, err)
+	}
+	if _, err := hkdrivers.RunSSHCommandFromDriver(d, fmt.Sprintf("sudo mkdir -p %s", dir)); err != nil {
+		return errors.Wrapf(err, "creating mount point %s", dir)
+	}
+	if _, err := hkdrivers.RunSSHCommandFromDriver(d, fmt.Sprintf("sudo mount -t nfs -o noacl,async %s:%s %s", hostIP, dir, dir)); err != nil {
+		return errors.Wrapf(err, "mounting %s", dir)
+	}
+
+	return nil
+}
+
+// teardownNFSShare removes the NFS export block owned by this machine, if
+// any, and refreshes nfsd so it stops serving a removed machine.
+func (d *Driver) teardownNFSShare() error {
+	if len(d.NFSShares) == 0 {
+		return nil
+	}
+
+	if _, err := nfsexports.Remove("", d.nfsExportIdentifier()); err != nil {
+		return errors.Wrap(err, "removing NFS share")
+	}
+
+	return errors.Wrap(nfsexports.ReloadDaemon(), "reloading nfsd")
+}
+
+func (d *Driver) UpdateConfigRaw(rawConfig []byte) error {
+	newDriver := Driver{
+		Driver: &hyperkitdriver.Driver{
+			VMDriver: &drivers.VMDriver{
+				BaseDriver: &drivers.BaseDriver{},
+			},
+		},
+	}
+	if err := json.Unmarshal(rawConfig, &newDriver); err != nil {
 		return err
 	}
 
-	if newDriver.Memory == d.Memory && newDriver.CPU == d.CPU {
-		/* For now only changing memory and CPU is supported/tested.
-		 * If none of these changed, we might be trying to change another
-		 * value, which is may or may not work, return ErrNotImplemented for now
+	s, err := d.GetState()
+	if err != nil {
+		log.Debugf("error checking machine status: %v, assuming it is stopped", err)
+		s = state.Stopped
+	}
+	running := s == state.Running
+
+	if newDriver.VMNet != d.VMNet && running {
+		return &UnsupportedLiveChangeError{Field: "VMNet"}
+	}
+	if newDriver.UUID != d.UUID && running {
+		return &UnsupportedLiveChangeError{Field: "UUID"}
+	}
+
+	if newDriver.DiskSize != d.DiskSize {
+		if newDriver.DiskSize < d.DiskSize {
+			return &DiskShrinkError{Old: d.DiskSize, New: newDriver.DiskSize}
+		}
+		if running {
+			return &UnsupportedLiveChangeError{Field: "DiskSize"}
+		}
+		if err := os.Truncate(d.getDiskPath(), int64(newDriver.DiskSize)*1000000); err != nil {
+			return errors.Wrap(err, "resizing disk")
+		}
+	}
+
+	if newDriver.ImageSourcePath != d.ImageSourcePath {
+		if running {
+			return &UnsupportedLiveChangeError{Field: "ImageSourcePath"}
+		}
+		if newDriver.ImageSourcePath != "" {
+			if err := copyFile(newDriver.ImageSourcePath, d.getDiskPath()); err != nil {
+				return errors.Wrap(err, "copying disk image")
+			}
+		}
+	}
+
+	supported := newDriver.Memory != d.Memory ||
+		newDriver.CPU != d.CPU ||
+		!reflect.DeepEqual(newDriver.NFSShares, d.NFSShares) ||
+		newDriver.NFSSharesRoot != d.NFSSharesRoot ||
+		newDriver.VMNet != d.VMNet ||
+		newDriver.UUID != d.UUID ||
+		newDriver.DiskSize != d.DiskSize ||
+		newDriver.ImageSourcePath != d.ImageSourcePath ||
+		!reflect.DeepEqual(newDriver.VSockPorts, d.VSockPorts) ||
+		newDriver.Cmdline != d.Cmdline ||
+		newDriver.VmlinuzPath != d.VmlinuzPath ||
+		newDriver.InitrdPath != d.InitrdPath
+	if !supported {
+		/* Nothing we recognise changed: it might be a field we don't
+		 * support mutating yet, return ErrNotImplemented for now.
 		 */
 		return drivers.ErrNotImplemented
 	}
+
 	*d = newDriver
 
 	return nil
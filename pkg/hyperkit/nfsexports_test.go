@@ -0,0 +1,23 @@
+//go:build darwin
+// +build darwin
+
+package hyperkit
+
+import "testing"
+
+func TestNfsExportIdentifier(t *testing.T) {
+	d := NewDriver()
+	d.MachineName = "minikube"
+
+	got := d.nfsExportIdentifier()
+	want := "machine-driver-hyperkit-minikube"
+	if got != want {
+		t.Fatalf("nfsExportIdentifier() = %q, want %q", got, want)
+	}
+
+	other := NewDriver()
+	other.MachineName = "other-machine"
+	if d.nfsExportIdentifier() == other.nfsExportIdentifier() {
+		t.Fatalf("two machines with different names produced the same identifier")
+	}
+}
@@ -0,0 +1,47 @@
+//go:build darwin
+// +build darwin
+
+package hyperkit
+
+import (
+	"path/filepath"
+	"testing"
+
+	"io/ioutil"
+)
+
+func TestReadPidFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "hyperkit.pid")
+	if err := ioutil.WriteFile(path, []byte("1234\n"), 0644); err != nil {
+		t.Fatalf("writing pid file: %v", err)
+	}
+
+	pid, err := readPidFromFile(path)
+	if err != nil {
+		t.Fatalf("readPidFromFile() unexpected error: %v", err)
+	}
+	if pid != 1234 {
+		t.Fatalf("readPidFromFile() = %d, want 1234", pid)
+	}
+}
+
+func TestReadPidFromFileMissing(t *testing.T) {
+	if _, err := readPidFromFile(filepath.Join(t.TempDir(), "does-not-exist")); err == nil {
+		t.Fatalf("readPidFromFile() expected an error for a missing file, got none")
+	}
+}
+
+func TestReadPidFromFileNotAnInt(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "hyperkit.pid")
+	if err := ioutil.WriteFile(path, []byte("not-a-pid"), 0644); err != nil {
+		t.Fatalf("writing pid file: %v", err)
+	}
+
+	if _, err := readPidFromFile(path); err == nil {
+		t.Fatalf("readPidFromFile() expected an error for a non-numeric pid file, got none")
+	}
+}
@@ -0,0 +1,73 @@
+//go:build darwin
+// +build darwin
+
+package hyperkit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/code-ready/machine/libmachine/drivers"
+)
+
+// rawConfigWith marshals d, then overrides the given top-level JSON fields,
+// mimicking the config a caller would round-trip through UpdateConfigRaw.
+func rawConfigWith(t *testing.T, d *Driver, overrides map[string]interface{}) []byte {
+	t.Helper()
+
+	base, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshaling driver: %v", err)
+	}
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(base, &cfg); err != nil {
+		t.Fatalf("unmarshaling driver: %v", err)
+	}
+	for k, v := range overrides {
+		cfg[k] = v
+	}
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshaling overridden config: %v", err)
+	}
+	return raw
+}
+
+func TestUpdateConfigRawDiskShrinkRejected(t *testing.T) {
+	d := NewDriver()
+	d.DiskSize = 20000
+
+	raw := rawConfigWith(t, d, map[string]interface{}{"DiskSize": 10000})
+
+	err := d.UpdateConfigRaw(raw)
+	if _, ok := err.(*DiskShrinkError); !ok {
+		t.Fatalf("UpdateConfigRaw() error = %v (%T), want *DiskShrinkError", err, err)
+	}
+}
+
+func TestUpdateConfigRawNoRecognisedChange(t *testing.T) {
+	d := NewDriver()
+
+	raw := rawConfigWith(t, d, nil)
+
+	err := d.UpdateConfigRaw(raw)
+	if err != drivers.ErrNotImplemented {
+		t.Fatalf("UpdateConfigRaw() error = %v, want drivers.ErrNotImplemented", err)
+	}
+}
+
+func TestUpdateConfigRawAppliesSupportedChange(t *testing.T) {
+	d := NewDriver()
+	d.Memory = DefaultMemory
+
+	raw := rawConfigWith(t, d, map[string]interface{}{"Memory": DefaultMemory + 1024})
+
+	if err := d.UpdateConfigRaw(raw); err != nil {
+		t.Fatalf("UpdateConfigRaw() unexpected error: %v", err)
+	}
+	if d.Memory != DefaultMemory+1024 {
+		t.Fatalf("Memory = %d, want %d", d.Memory, DefaultMemory+1024)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build darwin
+// +build darwin
+
+package hyperkit
+
+import "testing"
+
+func TestExtractVSockPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   []string
+		want    []int
+		wantErr bool
+	}{
+		{name: "none", ports: nil, want: []int{}},
+		{name: "single", ports: []string{"1234"}, want: []int{1234}},
+		{name: "multiple", ports: []string{"1234", "5678"}, want: []int{1234, 5678}},
+		{name: "not a number", ports: []string{"not-a-number"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDriver()
+			d.VSockPorts = tt.ports
+
+			got, err := d.extractVSockPorts()
+			if tt.wantErr {
+				if _, ok := err.(InvalidPortNumberError); !ok {
+					t.Fatalf("extractVSockPorts() error = %v, want InvalidPortNumberError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractVSockPorts() unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("extractVSockPorts() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("extractVSockPorts() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
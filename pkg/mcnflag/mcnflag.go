@@ -0,0 +1,67 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mcnflag describes the command line flags a driver registers
+// through drivers.Driver.GetCreateFlags, independently of however the
+// caller collects their values (CLI flags, environment variables, or an
+// RPC call to a plugin binary).
+package mcnflag
+
+// Flag is a flag that can be registered by a driver and later read back
+// through drivers.DriverOptions. String returns the flag's name, so a
+// slice of Flag can be range-printed or looked up without a type switch.
+type Flag interface {
+	String() string
+}
+
+// StringFlag is a flag that takes a single string value.
+type StringFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  string
+}
+
+func (f StringFlag) String() string { return f.Name }
+
+// StringSliceFlag is a flag that takes zero or more string values.
+type StringSliceFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  []string
+}
+
+func (f StringSliceFlag) String() string { return f.Name }
+
+// IntFlag is a flag that takes a single integer value.
+type IntFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+	Value  int
+}
+
+func (f IntFlag) String() string { return f.Name }
+
+// BoolFlag is a flag that is either present or absent.
+type BoolFlag struct {
+	Name   string
+	Usage  string
+	EnvVar string
+}
+
+func (f BoolFlag) String() string { return f.Name }
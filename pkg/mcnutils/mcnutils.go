@@ -0,0 +1,73 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mcnutils holds disk-image building helpers shared by drivers.
+package mcnutils
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+)
+
+// MakeDiskImage makes a boot2docker-style tar disk image, containing the
+// public SSH key found at publicSSHKeyPath so the guest can authorize the
+// host for SSH access.
+func MakeDiskImage(publicSSHKeyPath string) (*bytes.Buffer, error) {
+	publicKey, err := ioutil.ReadFile(publicSSHKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     ".ssh",
+		Mode:     0700,
+		Typeflag: tar.TypeDir,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name: ".ssh/authorized_keys",
+		Size: int64(len(publicKey)),
+		Mode: 0644,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(publicKey); err != nil {
+		return nil, err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name: ".ssh/authorized_keys2",
+		Size: int64(len(publicKey)),
+		Mode: 0644,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(publicKey); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sshutil holds the SSH key material helpers drivers need to seed
+// a guest's authorized_keys, independently of any particular driver.
+package sshutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+)
+
+// GenerateSSHKey creates an RSA keypair at path (private key) and path+".pub"
+// (public key, in OpenSSH authorized_keys format), unless a key already
+// exists at path.
+func GenerateSSHKey(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	privDER := x509.MarshalPKCS1PrivateKey(priv)
+	privBlock := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: privDER,
+	}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&privBlock), 0600); err != nil {
+		return err
+	}
+
+	pubBytes, err := marshalAuthorizedKey(&priv.PublicKey)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".pub", pubBytes, 0644); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// marshalAuthorizedKey renders an RSA public key in the "ssh-rsa AAAA..."
+// format accepted by an authorized_keys file.
+func marshalAuthorizedKey(pub *rsa.PublicKey) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeString := func(s string) {
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(s)))
+		buf.Write(lenBuf[:])
+		buf.WriteString(s)
+	}
+	writeBigInt := func(n []byte) {
+		// Prepend a zero byte if the high bit is set, so the value is not
+		// misread as negative per the SSH wire format for mpints.
+		if len(n) > 0 && n[0]&0x80 != 0 {
+			n = append([]byte{0}, n...)
+		}
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(n)))
+		buf.Write(lenBuf[:])
+		buf.Write(n)
+	}
+
+	writeString("ssh-rsa")
+	writeBigInt(big.NewInt(int64(pub.E)).Bytes())
+	writeBigInt(pub.N.Bytes())
+
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+	return []byte(fmt.Sprintf("ssh-rsa %s\n", encoded)), nil
+}